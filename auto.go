@@ -0,0 +1,79 @@
+package uidgen
+
+import (
+	"errors"
+	"hash/fnv"
+	"net"
+	"os"
+)
+
+// ErrNoMachineID is returned by NewUidGeneratorAuto when neither a MAC address
+// nor a hostname is available to derive a server ID from.
+var ErrNoMachineID = errors.New("no MAC address or hostname available to derive a server ID")
+
+// NewUidGeneratorAuto is like NewUidGenerator, but if cfg.SrvID is -1 it derives
+// a server ID from the machine's MAC address (hostname as a fallback) and the
+// current process ID, the way rs/xid picks a machine+process identifier. This
+// lets a generator be dropped into a container or serverless environment
+// without a coordination service.
+func NewUidGeneratorAuto(cfg UidGeneratorConfig, prevID UniqueID) (*UidGenerator, error) {
+	if cfg.SrvID == -1 {
+		mid, err := MachineID()
+		if err != nil {
+			return nil, err
+		}
+
+		h := fnv.New64a()
+		h.Write(mid)
+		h.Write([]byte{byte(ProcessID()), byte(ProcessID() >> 8)})
+
+		// SrvLen isn't populated yet when DcLen/WorkerLen are used instead
+		// (cfg.update, called from NewUidGenerator below, derives it), so
+		// compute the real bit width ourselves and fold into whichever
+		// field NewUidGenerator will actually read back out.
+		srvLen := cfg.SrvLen
+		if cfg.DcLen != 0 || cfg.WorkerLen != 0 {
+			srvLen = cfg.DcLen + cfg.WorkerLen
+		}
+
+		maxSrv := uint64(1)<<srvLen - 1
+		srv := int64(h.Sum64() % (maxSrv + 1))
+
+		if cfg.DcLen != 0 || cfg.WorkerLen != 0 {
+			cfg.DcID = srv >> cfg.WorkerLen
+			cfg.WorkerID = srv & (1<<cfg.WorkerLen - 1)
+		} else {
+			cfg.SrvID = srv
+		}
+	}
+
+	return NewUidGenerator(cfg, prevID)
+}
+
+// MachineID returns the bytes NewUidGeneratorAuto hashes to derive a server ID:
+// the hardware address of the first non-loopback network interface, or the
+// hostname if no such interface is found.
+func MachineID() ([]byte, error) {
+	ifaces, err := net.Interfaces()
+	if err == nil {
+		for _, iface := range ifaces {
+			if len(iface.HardwareAddr) == 0 || iface.Flags&net.FlagLoopback != 0 {
+				continue
+			}
+
+			return iface.HardwareAddr, nil
+		}
+	}
+
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return nil, ErrNoMachineID
+	}
+
+	return []byte(host), nil
+}
+
+// ProcessID returns the current process ID, folded to 16 bits.
+func ProcessID() uint16 {
+	return uint16(os.Getpid())
+}
@@ -0,0 +1,156 @@
+package uidgen
+
+import (
+	"database/sql/driver"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// DefaultGenerator is used by UniqueID's MarshalText/UnmarshalText to encode and
+// decode the Base32 text form without threading a *UidGenerator through every
+// call site. Set it once at startup with SetDefault.
+var DefaultGenerator *UidGenerator
+
+// SetDefault sets the package-level generator used by UniqueID's MarshalText/UnmarshalText.
+func SetDefault(gen *UidGenerator) {
+	DefaultGenerator = gen
+}
+
+// ErrInvalidBinaryUID is returned by UnmarshalBinary when given a slice that isn't 8 bytes long.
+var ErrInvalidBinaryUID = errors.New("invalid binary UniqueID: must be 8 bytes")
+
+// base32hex is the fallback text encoding used when DefaultGenerator is unset.
+var base32hex = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// MarshalBinary encodes id as 8 big-endian bytes.
+func (id UniqueID) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+
+	return b, nil
+}
+
+// UnmarshalBinary decodes id from 8 big-endian bytes, as produced by MarshalBinary.
+func (id *UniqueID) UnmarshalBinary(b []byte) error {
+	if len(b) != 8 {
+		return ErrInvalidBinaryUID
+	}
+
+	*id = UniqueID(binary.BigEndian.Uint64(b))
+
+	return nil
+}
+
+// MarshalText encodes id with DefaultGenerator's Base32 alphabet if one has been
+// set via SetDefault, otherwise with base32hex.
+func (id UniqueID) MarshalText() ([]byte, error) {
+	if DefaultGenerator != nil {
+		return []byte(DefaultGenerator.ToBase32(id)), nil
+	}
+
+	b, _ := id.MarshalBinary()
+
+	return []byte(base32hex.EncodeToString(b)), nil
+}
+
+// UnmarshalText decodes text produced by MarshalText, using DefaultGenerator's
+// Base32 alphabet if one has been set via SetDefault, otherwise base32hex.
+func (id *UniqueID) UnmarshalText(text []byte) error {
+	if DefaultGenerator != nil {
+		v, err := DefaultGenerator.FromBase32(string(text))
+		if err != nil {
+			return err
+		}
+
+		*id = v
+
+		return nil
+	}
+
+	b, err := base32hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+
+	return id.UnmarshalBinary(b)
+}
+
+// MarshalJSON encodes id as a JSON string, since JSON numbers lose precision past 2^53.
+func (id UniqueID) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + id.String() + `"`), nil
+}
+
+// UnmarshalJSON decodes id from a JSON string produced by MarshalJSON.
+func (id *UniqueID) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+
+	v, err := FromString(s)
+	if err != nil {
+		return err
+	}
+
+	*id = v
+
+	return nil
+}
+
+// Value implements database/sql/driver.Valuer.
+func (id UniqueID) Value() (driver.Value, error) {
+	return int64(id), nil
+}
+
+// Scan implements database/sql.Scanner.
+func (id *UniqueID) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case int64:
+		*id = UniqueID(v)
+	case []byte:
+		switch len(v) {
+		case 8:
+			return id.UnmarshalBinary(v)
+		case 16:
+			var u [16]byte
+			copy(u[:], v)
+			*id = FromUUID(u)
+		default:
+			n, err := FromString(string(v))
+			if err != nil {
+				return err
+			}
+
+			*id = n
+		}
+	case string:
+		n, err := FromString(v)
+		if err != nil {
+			return err
+		}
+
+		*id = n
+	case nil:
+		*id = 0
+	default:
+		return fmt.Errorf("uidgen: cannot scan %T into UniqueID", src)
+	}
+
+	return nil
+}
+
+// ToUUID returns id padded into a 16-byte array suitable for storage in a UUID
+// column, with id in the low 8 bytes and the high 8 bytes zeroed.
+func (id UniqueID) ToUUID() [16]byte {
+	var u [16]byte
+	binary.BigEndian.PutUint64(u[8:], uint64(id))
+
+	return u
+}
+
+// FromUUID returns the UniqueID encoded in the low 8 bytes of a ToUUID array.
+func FromUUID(u [16]byte) UniqueID {
+	return UniqueID(binary.BigEndian.Uint64(u[8:]))
+}
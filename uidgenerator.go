@@ -4,6 +4,7 @@ import (
 	"math"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"errors"
@@ -12,37 +13,65 @@ import (
 // UidGeneratorConfig is the configuration for UidGenerator.
 type UidGeneratorConfig struct {
 	EpochLen    uint8 // EpochLen is the bit length of the epoch field.
-	SrvLen      uint8 // SrvLen is the bit length of the server ID field.
+	SrvLen      uint8 // SrvLen is the bit length of the server ID field. Overridden by DcLen+WorkerLen when either is set.
+	DcLen       uint8 // DcLen is the bit length of the data center ID sub-field of the server ID. 0 keeps SrvLen/SrvID as a single field.
+	WorkerLen   uint8 // WorkerLen is the bit length of the worker ID sub-field of the server ID.
 	CntLen      uint8 // CntLen is the bit length of the sequence counter field.
+	TickTockLen uint8 // TickTockLen is the bit length of the tick-tock flag reserved between the counter and the server ID. It is flipped instead of sleeping whenever the clock moves backwards or the counter overflows within an interval. 0 disables the feature.
+	MetaLen     uint8 // MetaLen is the bit length of the metabyte field reserved between the server ID and the tick-tock flag for caller-defined payload data, e.g. NextIDWithMeta. 0 disables the feature.
 	IntervalLen uint8 // IntervalLen is the bit length of the time interval. Defaults to 61 - EpochLen.
+	LockFree    bool  // LockFree selects the atomic CAS implementation of NextID instead of the mutex-guarded one. Higher throughput under heavy concurrent use; kept optional for correctness comparison against the mutex path.
 	TruncStr    bool  // TruncStr allows you to truncate the last zeroes when converting to string.
 	EpochStart  int64 // EpochStart is the point in time since which the UniqueID time is defined as elapsed.
-	SrvID       int64 // SrvID is the current server ID. Must be less than 2^SrvLen.
+	SrvID       int64 // SrvID is the current server ID. Must be less than 2^SrvLen. Ignored when DcLen or WorkerLen is set.
+	DcID        int64 // DcID is the current data center ID. Must be less than 2^DcLen. Ignored unless DcLen or WorkerLen is set.
+	WorkerID    int64 // WorkerID is the current worker ID. Must be less than 2^WorkerLen. Ignored unless DcLen or WorkerLen is set.
 
 	strLen     int8
 	epochShift uint8
 	epochMask  UniqueID
 	epochIota  UniqueID
 	maxSrv     UniqueID
+	srvShift   uint8
 	srvMask    UniqueID
 	maxCnt     UniqueID
 	cntMask    UniqueID
+	tickShift  uint8
+	tickMask   UniqueID
+	tickIota   UniqueID
+	maxMeta    UniqueID
+	metaShift  uint8
+	metaMask   UniqueID
 	interval   int64
 	timeMask   int64
 }
 
 func (cfg *UidGeneratorConfig) update() error {
-	idLen := cfg.EpochLen + cfg.SrvLen + cfg.CntLen
+	if cfg.DcLen != 0 || cfg.WorkerLen != 0 {
+		cfg.SrvLen = cfg.DcLen + cfg.WorkerLen
+	}
+
+	idLen := cfg.EpochLen + cfg.SrvLen + cfg.MetaLen + cfg.TickTockLen + cfg.CntLen
 	if idLen > 64 {
 		return ErrTooLongID
 	}
 
 	cfg.strLen = int8(math.Ceil(float64(idLen) / letterLen))
-	cfg.epochShift = cfg.SrvLen + cfg.CntLen
+	cfg.tickShift = cfg.CntLen
+	cfg.tickMask = (1<<cfg.TickTockLen - 1) << cfg.tickShift
+	cfg.tickIota = 0
+	if cfg.TickTockLen > 0 {
+		cfg.tickIota = 1 << cfg.tickShift
+	}
+	cfg.metaShift = cfg.CntLen + cfg.TickTockLen
+	cfg.maxMeta = 1<<cfg.MetaLen - 1
+	cfg.metaMask = cfg.maxMeta << cfg.metaShift
+	cfg.srvShift = cfg.metaShift + cfg.MetaLen
+	cfg.epochShift = cfg.srvShift + cfg.SrvLen
 	cfg.epochMask = (1<<cfg.EpochLen - 1) << cfg.epochShift
 	cfg.epochIota = 1 << cfg.epochShift
 	cfg.maxSrv = 1<<cfg.SrvLen - 1
-	cfg.srvMask = cfg.maxSrv << cfg.CntLen
+	cfg.srvMask = cfg.maxSrv << cfg.srvShift
 	cfg.maxCnt = 1<<cfg.CntLen - 1
 	cfg.cntMask = cfg.maxCnt
 
@@ -67,10 +96,27 @@ var SnowflakeConfig = UidGeneratorConfig{
 	IntervalLen: 20,
 }
 
+// TwitterSnowflakeConfig splits the 10 machine bits of the original Twitter Snowflake
+// layout into a 5-bit data center ID and a 5-bit worker ID, so IDs produced by
+// existing Java/Scala Snowflake services can be decoded with DataCenterID/WorkerID.
+var TwitterSnowflakeConfig = UidGeneratorConfig{
+	EpochLen:    41,
+	DcLen:       5,
+	WorkerLen:   5,
+	CntLen:      12,
+	EpochStart:  1288834974, // 2010-11-04T01:42:54
+	IntervalLen: 20,
+}
+
 const (
 	letters    = "abcdefghijklmnopqrstuvwxyzABCDEF"
 	letterLen  = 5
 	letterMask = 1<<letterLen - 1
+
+	// maxBatchIntervals bounds how many epoch intervals NextIDs/AppendNextIDs
+	// may spill into when reserving a batch, to keep n from silently hanging
+	// onto a huge slice of future time.
+	maxBatchIntervals = 1 << 10
 )
 
 var decodeLetters [256]byte
@@ -84,6 +130,9 @@ var ErrTooBigServerID = errors.New("server ID is too big")
 // ErrTooLongID is returned by NewUidGenerator if length of IDs would exceed 64 bits
 var ErrTooLongID = errors.New("configured ID length is too big")
 
+// ErrTooManyIDs is returned by NextIDs/AppendNextIDs when n exceeds maxBatchIntervals worth of sequence slots
+var ErrTooManyIDs = errors.New("requested too many IDs at once")
+
 func init() {
 	for i := 0; i < len(letters); i++ {
 		decodeLetters[i] = 0xFF
@@ -106,6 +155,11 @@ type UidGenerator struct {
 	epoch UniqueID
 	srvID UniqueID
 	cnt   UniqueID
+	tick  UniqueID
+
+	// state packs epoch|tick|cnt into one word for the LockFree path. It is
+	// accessed only via the sync/atomic functions.
+	state uint64
 }
 
 // NewUidGenerator returns a new UidGenerator configured with the specified UidGeneratorConfig.
@@ -117,6 +171,17 @@ func NewUidGenerator(cfg UidGeneratorConfig, prevID UniqueID) (*UidGenerator, er
 		return nil, err
 	}
 
+	if cfg.DcLen != 0 || cfg.WorkerLen != 0 {
+		maxDc := int64(1)<<cfg.DcLen - 1
+		maxWorker := int64(1)<<cfg.WorkerLen - 1
+
+		if cfg.DcID > maxDc || cfg.WorkerID > maxWorker {
+			return nil, ErrTooBigServerID
+		}
+
+		cfg.SrvID = cfg.DcID<<cfg.WorkerLen | cfg.WorkerID
+	}
+
 	if cfg.SrvID > int64(cfg.maxSrv) {
 		return nil, ErrTooBigServerID
 	}
@@ -124,8 +189,10 @@ func NewUidGenerator(cfg UidGeneratorConfig, prevID UniqueID) (*UidGenerator, er
 	gen := &UidGenerator{
 		cfg:   cfg,
 		epoch: prevID & cfg.epochMask,
-		srvID: UniqueID(cfg.SrvID << cfg.CntLen),
+		srvID: UniqueID(cfg.SrvID) << cfg.srvShift,
 		cnt:   prevID & cfg.cntMask,
+		tick:  prevID & cfg.tickMask,
+		state: uint64(prevID & (cfg.epochMask | cfg.tickMask | cfg.cntMask)),
 	}
 
 	now := time.Now()
@@ -135,27 +202,53 @@ func NewUidGenerator(cfg UidGeneratorConfig, prevID UniqueID) (*UidGenerator, er
 }
 
 // NextID generates a next UniqueID.
+// Instead of sleeping through clock regressions or counter exhaustion, NextID uses the
+// tick-tock technique: on a clock regression it keeps counting in the stored epoch
+// interval, on counter exhaustion it steals the next epoch interval, and in both cases
+// it flips the lowest bit of the TickTockLen-bit tick-tock field, so the caller is
+// never blocked.
 func (gen *UidGenerator) NextID() UniqueID {
+	return gen.nextID(0)
+}
+
+// NextIDWithMeta generates a next UniqueID that carries meta in its MetaLen-bit
+// metabyte region, e.g. an event type, tenant, or shard hint. Bits of meta above
+// MetaLen are discarded.
+func (gen *UidGenerator) NextIDWithMeta(meta uint64) UniqueID {
+	return gen.nextID((UniqueID(meta) & gen.cfg.maxMeta) << gen.cfg.metaShift)
+}
+
+func (gen *UidGenerator) nextID(metaBits UniqueID) UniqueID {
+	if gen.cfg.LockFree {
+		return gen.nextIDLockFree(metaBits)
+	}
+
+	return gen.nextIDLocked(metaBits)
+}
+
+func (gen *UidGenerator) nextIDLocked(metaBits UniqueID) UniqueID {
 	since := time.Since(gen.start).Nanoseconds() >> gen.cfg.IntervalLen
 	epoch := UniqueID(since) << gen.cfg.epochShift & gen.cfg.epochMask
 
 	gen.mu.Lock()
 
 	if epoch <= gen.epoch {
+		if epoch < gen.epoch {
+			gen.tick ^= gen.cfg.tickIota
+		}
+
 		gen.cnt++
 		if gen.cnt > gen.cfg.maxCnt {
-			nsec := gen.cfg.interval - int64(time.Now().Nanosecond())&gen.cfg.timeMask
-			time.Sleep(time.Duration(nsec) * time.Nanosecond)
-
 			gen.epoch = gen.epoch + gen.cfg.epochIota
 			gen.cnt = 0
+			gen.tick ^= gen.cfg.tickIota
 		}
 	} else {
 		gen.epoch = epoch
 		gen.cnt = 0
 	}
 
-	id := gen.epoch + gen.srvID + gen.cnt
+	id := gen.epoch + gen.srvID + metaBits + gen.tick + gen.cnt
 
 	// We don't use "defer" here to improve performance.
 	gen.mu.Unlock()
@@ -163,6 +256,151 @@ func (gen *UidGenerator) NextID() UniqueID {
 	return id
 }
 
+// NextIDs reserves n sequential UniqueIDs in one critical section instead of
+// once per ID as a loop over NextID would.
+func (gen *UidGenerator) NextIDs(n int) ([]UniqueID, error) {
+	return gen.AppendNextIDs(nil, n)
+}
+
+// AppendNextIDs is like NextIDs but appends the reserved IDs to dst and returns
+// the extended slice, so callers can reuse a buffer across calls. It advances
+// the same counter NextID does for the configured generator (gen.state when
+// cfg.LockFree is set, the mutex-guarded fields otherwise), so the two APIs
+// can be interleaved on one generator without colliding.
+func (gen *UidGenerator) AppendNextIDs(dst []UniqueID, n int) ([]UniqueID, error) {
+	if n <= 0 {
+		return dst, nil
+	}
+
+	if UniqueID(n) > gen.cfg.maxCnt*maxBatchIntervals {
+		return nil, ErrTooManyIDs
+	}
+
+	if gen.cfg.LockFree {
+		return gen.appendNextIDsLockFree(dst, n)
+	}
+
+	return gen.appendNextIDsLocked(dst, n)
+}
+
+func (gen *UidGenerator) appendNextIDsLocked(dst []UniqueID, n int) ([]UniqueID, error) {
+	since := time.Since(gen.start).Nanoseconds() >> gen.cfg.IntervalLen
+	epoch := UniqueID(since) << gen.cfg.epochShift & gen.cfg.epochMask
+
+	gen.mu.Lock()
+
+	if epoch <= gen.epoch {
+		if epoch < gen.epoch {
+			gen.tick ^= gen.cfg.tickIota
+		}
+	} else {
+		gen.epoch = epoch
+		gen.cnt = 0
+	}
+
+	for i := 0; i < n; i++ {
+		gen.cnt++
+		if gen.cnt > gen.cfg.maxCnt {
+			gen.epoch += gen.cfg.epochIota
+			gen.cnt = 0
+			gen.tick ^= gen.cfg.tickIota
+		}
+
+		dst = append(dst, gen.epoch+gen.srvID+gen.tick+gen.cnt)
+	}
+
+	gen.mu.Unlock()
+
+	return dst, nil
+}
+
+// appendNextIDsLockFree is the LockFree counterpart of appendNextIDsLocked: it
+// simulates the n-step advance on a local copy of gen.state and commits the
+// whole batch with a single CAS, retrying from scratch on contention so the
+// reservation stays atomic with respect to concurrent NextID/NextIDs calls.
+func (gen *UidGenerator) appendNextIDsLockFree(dst []UniqueID, n int) ([]UniqueID, error) {
+	since := time.Since(gen.start).Nanoseconds() >> gen.cfg.IntervalLen
+	epoch := UniqueID(since) << gen.cfg.epochShift & gen.cfg.epochMask
+
+	for {
+		old := atomic.LoadUint64(&gen.state)
+		curEpoch := UniqueID(old) & gen.cfg.epochMask
+		tick := UniqueID(old) & gen.cfg.tickMask
+		cnt := UniqueID(old) & gen.cfg.cntMask
+
+		if epoch <= curEpoch {
+			if epoch < curEpoch {
+				tick ^= gen.cfg.tickIota
+			}
+		} else {
+			curEpoch = epoch
+			cnt = 0
+		}
+
+		ids := make([]UniqueID, 0, n)
+
+		for i := 0; i < n; i++ {
+			cnt++
+			if cnt > gen.cfg.maxCnt {
+				curEpoch += gen.cfg.epochIota
+				cnt = 0
+				tick ^= gen.cfg.tickIota
+			}
+
+			ids = append(ids, curEpoch+gen.srvID+tick+cnt)
+		}
+
+		state := uint64(curEpoch | tick | cnt)
+
+		if atomic.CompareAndSwapUint64(&gen.state, old, state) {
+			return append(dst, ids...), nil
+		}
+	}
+}
+
+// nextIDLockFree is the LockFree counterpart of nextIDLocked: it packs
+// epoch|tick|cnt into gen.state and advances it with a CAS spin loop instead
+// of a mutex, so concurrent callers never block on each other. A successful
+// CompareAndSwapUint64 establishes a happens-before edge with every later
+// load of gen.state, so the epoch/tick/cnt triple read back by the winning
+// goroutine (and by every subsequent NextID call) is always the most recent
+// one — no explicit memory barrier is needed beyond what sync/atomic provides.
+func (gen *UidGenerator) nextIDLockFree(metaBits UniqueID) UniqueID {
+	since := time.Since(gen.start).Nanoseconds() >> gen.cfg.IntervalLen
+	epoch := UniqueID(since) << gen.cfg.epochShift & gen.cfg.epochMask
+
+	for {
+		old := atomic.LoadUint64(&gen.state)
+		oldEpoch := UniqueID(old) & gen.cfg.epochMask
+		tick := UniqueID(old) & gen.cfg.tickMask
+		cnt := UniqueID(old) & gen.cfg.cntMask
+
+		newEpoch := oldEpoch
+
+		if epoch <= oldEpoch {
+			if epoch < oldEpoch {
+				tick ^= gen.cfg.tickIota
+			}
+
+			cnt++
+			if cnt > gen.cfg.maxCnt {
+				newEpoch = oldEpoch + gen.cfg.epochIota
+				cnt = 0
+				tick ^= gen.cfg.tickIota
+			}
+		} else {
+			newEpoch = epoch
+			cnt = 0
+		}
+
+		state := uint64(newEpoch | tick | cnt)
+
+		if atomic.CompareAndSwapUint64(&gen.state, old, state) {
+			return newEpoch + gen.srvID + metaBits + tick + cnt
+		}
+	}
+}
+
 // FromBase32 returns a UniqueID parsed from the string.
 func (gen *UidGenerator) FromBase32(str string) (UniqueID, error) {
 	var id UniqueID
@@ -235,9 +473,32 @@ func (gen *UidGenerator) UnixNano(id UniqueID) int64 {
 	return int64(id>>gen.cfg.epochShift<<gen.cfg.IntervalLen) + gen.cfg.EpochStart*1e9
 }
 
+// FromMetaUnix returns a new UniqueID with the specified time in seconds since Unix epoch
+// and meta encoded in its MetaLen-bit metabyte region, mirroring FromUnix.
+func (gen *UidGenerator) FromMetaUnix(epoch int64, meta uint64) UniqueID {
+	return gen.FromUnix(epoch) + (UniqueID(meta)&gen.cfg.maxMeta)<<gen.cfg.metaShift
+}
+
 // ServerID returns the server ID.
 func (gen *UidGenerator) ServerID(id UniqueID) int64 {
-	return int64(id&gen.cfg.srvMask) >> gen.cfg.CntLen
+	return int64(id&gen.cfg.srvMask) >> gen.cfg.srvShift
+}
+
+// DataCenterID returns the data center ID sub-field of the server ID.
+// It is only meaningful when the generator was configured with DcLen or WorkerLen.
+func (gen *UidGenerator) DataCenterID(id UniqueID) int64 {
+	return gen.ServerID(id) >> gen.cfg.WorkerLen
+}
+
+// WorkerID returns the worker ID sub-field of the server ID.
+// It is only meaningful when the generator was configured with DcLen or WorkerLen.
+func (gen *UidGenerator) WorkerID(id UniqueID) int64 {
+	return gen.ServerID(id) & (1<<gen.cfg.WorkerLen - 1)
+}
+
+// Meta returns the metabyte encoded by NextIDWithMeta or FromMetaUnix.
+func (gen *UidGenerator) Meta(id UniqueID) int64 {
+	return int64(id&gen.cfg.metaMask) >> gen.cfg.metaShift
 }
 
 // Count returns the sequence counter.
@@ -0,0 +1,95 @@
+package uidgen
+
+import (
+	"testing"
+	"time"
+)
+
+// driveClockBackwards pushes gen's stored epoch far ahead of the real wall
+// clock, simulating a system clock that has jumped backwards relative to
+// what the generator last observed.
+func driveClockBackwards(gen *UidGenerator, intervals UniqueID) {
+	since := time.Since(gen.start).Nanoseconds() >> gen.cfg.IntervalLen
+	now := UniqueID(since) << gen.cfg.epochShift & gen.cfg.epochMask
+	gen.epoch = now + gen.cfg.epochIota*intervals
+}
+
+func TestNextIDNoBlockNoCollisionOnClockRegression(t *testing.T) {
+	cfg := UidGeneratorConfig{
+		EpochLen:    30,
+		SrvLen:      4,
+		CntLen:      2,
+		TickTockLen: 1,
+		IntervalLen: 30,
+	}
+
+	gen, err := NewUidGenerator(cfg, 0)
+	if err != nil {
+		t.Fatalf("NewUidGenerator: %v", err)
+	}
+
+	driveClockBackwards(gen, 1000)
+
+	seen := make(map[UniqueID]bool)
+	start := time.Now()
+
+	for i := 0; i < 500; i++ {
+		id := gen.NextID()
+		if seen[id] {
+			t.Fatalf("collision on iteration %d: id %d generated twice", i, id)
+		}
+		seen[id] = true
+	}
+
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Fatalf("NextID appears to have blocked: 500 calls took %s", elapsed)
+	}
+}
+
+func TestNextIDTickTockDoesNotCorruptServerID(t *testing.T) {
+	cfg := UidGeneratorConfig{
+		EpochLen:    30,
+		SrvLen:      4,
+		CntLen:      2,
+		TickTockLen: 1,
+		IntervalLen: 30,
+		SrvID:       5,
+	}
+
+	gen, err := NewUidGenerator(cfg, 0)
+	if err != nil {
+		t.Fatalf("NewUidGenerator: %v", err)
+	}
+
+	driveClockBackwards(gen, 1000)
+
+	for i := 0; i < 200; i++ {
+		id := gen.NextID()
+		if srv := gen.ServerID(id); srv != cfg.SrvID {
+			t.Fatalf("iteration %d: ServerID = %d, want %d (tick-tock bit leaked into the server ID)", i, srv, cfg.SrvID)
+		}
+	}
+}
+
+// TestNextIDWithoutTickTockDoesNotCorruptServerID guards against a regression
+// where tickIota was computed even for TickTockLen == 0, so the tick flip on
+// overflow/regression landed on the lowest bit of the server ID.
+func TestNextIDWithoutTickTockDoesNotCorruptServerID(t *testing.T) {
+	cfg := SnowflakeConfig
+	cfg.IntervalLen = 30
+	cfg.SrvID = 3
+
+	gen, err := NewUidGenerator(cfg, 0)
+	if err != nil {
+		t.Fatalf("NewUidGenerator: %v", err)
+	}
+
+	driveClockBackwards(gen, 1000)
+
+	for i := 0; i < 2*int(gen.cfg.maxCnt+1); i++ {
+		id := gen.NextID()
+		if srv := gen.ServerID(id); srv != cfg.SrvID {
+			t.Fatalf("iteration %d: ServerID = %d, want %d", i, srv, cfg.SrvID)
+		}
+	}
+}